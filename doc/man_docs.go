@@ -0,0 +1,498 @@
+package doc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cpuguy83/go-md2man/v2/md2man"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// GenManHeader is a lot like the .TH header at the start of man pages. These
+// include the title, section, date, source, and manual. We will use the
+// current time if Date is unset and will use "Auto generated by spf13/cobra"
+// if the Source is unset.
+type GenManHeader struct {
+	Title   string
+	Section string
+	Date    *time.Time
+	date    string
+	Source  string
+	Manual  string
+}
+
+// GenManTreeOptions controls the behavior of GenManTreeFromOpts.
+type GenManTreeOptions struct {
+	// Header is the template man page header shared by every generated
+	// page. A copy is used for each command so callers may freely mutate
+	// Title, Section, etc. between invocations without it leaking across
+	// commands. May be nil.
+	Header *GenManHeader
+	// Path is the directory the pages are written into, relative to Fs.
+	Path string
+	// CommandSeparator joins a command's path components into a file
+	// name, e.g. "-" turns "root sub cmd" into "root-sub-cmd.1". Defaults
+	// to "-".
+	CommandSeparator string
+	// Compress gzips each generated page and appends a ".gz" suffix to
+	// its filename, matching how most Linux distributions ship man
+	// pages.
+	Compress bool
+	// CompressLevel is passed to gzip.NewWriterLevel when Compress is
+	// set. Defaults to gzip.DefaultCompression when left at its zero
+	// value, since gzip.DefaultCompression is itself -1, not 0.
+	CompressLevel int
+	// SectionDirs routes each page into a "manN" subdirectory of Path,
+	// where N is the command's section (see ManSectionAnnotation), and
+	// confines all writes to Path via afero.NewBasePathFs so a command
+	// can't escape it (e.g. via ".." in its name).
+	SectionDirs bool
+}
+
+// ManSectionAnnotation is the Command.Annotations key used to override the
+// man section a single command's page is filed under, e.g. Annotations:
+// map[string]string{ManSectionAnnotation: "5"} for a config file command
+// that otherwise lives under a section "1" tree. Falls back to
+// GenManHeader.Section, then to "1", when unset.
+const ManSectionAnnotation = "man.section"
+
+// GenManTree will generate a man page for this command and all descendants
+// in the directory given. The header may be nil. This function may not work
+// correctly if your command names have `-` in them. If you have `cmd` with
+// two subcmds, `sub` and `sub-third`, and `sub` has a subcommand called
+// `third`, it is undefined which help output will be in the file
+// `cmd-sub-third.1`.
+func GenManTree(cmd *cobra.Command, header *GenManHeader, dir string) error {
+	return GenManTreeFromOpts(cmd, GenManTreeOptions{
+		Header:           header,
+		Path:             dir,
+		CommandSeparator: "-",
+	})
+}
+
+// GenManTreeFromOpts generates a man page for the command and all
+// descendants. The pages are written to opts.Path using docFs (see SetFS),
+// so callers can redirect generation to a MemMapFs, a BasePathFs, or any
+// other afero.Fs without touching the real disk.
+func GenManTreeFromOpts(cmd *cobra.Command, opts GenManTreeOptions) error {
+	return genManTreeFromOpts(cmd, opts, docFs)
+}
+
+// genManTreeFromOpts is GenManTreeFromOpts with the effective filesystem
+// passed in explicitly, rather than read from the package-level docFs. This
+// lets GenManTreeIncremental render through its own overlay filesystem
+// without mutating docFs for the duration of the call, which would race
+// against any other concurrent GenManTreeFromOpts/GenManTreeIncremental call
+// in the same process.
+func genManTreeFromOpts(cmd *cobra.Command, opts GenManTreeOptions, fs *afero.Afero) error {
+	header := opts.Header
+	if header == nil {
+		header = &GenManHeader{}
+	}
+
+	path := opts.Path
+	if opts.SectionDirs {
+		fs = &afero.Afero{Fs: afero.NewBasePathFs(fs.Fs, opts.Path)}
+		path = ""
+	}
+
+	return genManTree(cmd, header, path, opts, fs)
+}
+
+// genManTree is the recursive worker behind genManTreeFromOpts. It is kept
+// separate so the SectionDirs BasePathFs is only ever installed once, at
+// the top of genManTreeFromOpts, instead of being re-wrapped on every
+// recursive call.
+func genManTree(cmd *cobra.Command, header *GenManHeader, path string, opts GenManTreeOptions, fs *afero.Afero) error {
+	for _, c := range availableCommands(cmd) {
+		if err := genManTree(c, header, path, opts, fs); err != nil {
+			return err
+		}
+	}
+
+	section := effectiveSection(cmd, header)
+	dir := path
+	if opts.SectionDirs {
+		dir = filepath.Join(path, "man"+section)
+	}
+	filename := filepath.Join(dir, manPageName(cmd, section, opts.CommandSeparator, opts.Compress))
+
+	if err := fs.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	f, err := fs.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var w io.Writer = f
+	if opts.Compress {
+		level := opts.CompressLevel
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		gz, err := gzip.NewWriterLevel(f, level)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		w = gz
+	}
+
+	headerCopy := *header
+	headerCopy.Section = section
+	return GenMan(cmd, &headerCopy, w)
+}
+
+// effectiveSection returns the man section cmd's page belongs under: its own
+// ManSectionAnnotation override if set, otherwise header.Section, otherwise
+// "1".
+func effectiveSection(cmd *cobra.Command, header *GenManHeader) string {
+	if cmd.Annotations != nil {
+		if section, ok := cmd.Annotations[ManSectionAnnotation]; ok && section != "" {
+			return section
+		}
+	}
+	if header.Section != "" {
+		return header.Section
+	}
+	return "1"
+}
+
+// manPageName returns the file name (without directory) a command's page is
+// written to, applying the command separator and, when compress is set, the
+// ".gz" suffix.
+func manPageName(cmd *cobra.Command, section, separator string, compress bool) string {
+	if separator == "" {
+		separator = "-"
+	}
+	basename := strings.Replace(cmd.CommandPath(), " ", separator, -1)
+	name := basename + "." + section
+	if compress {
+		name += ".gz"
+	}
+	return name
+}
+
+// availableCommands returns cmd's children that are eligible for their own
+// generated page, i.e. the same filter GenManTreeFromOpts uses to decide
+// which subcommands to recurse into.
+func availableCommands(cmd *cobra.Command) []*cobra.Command {
+	available := make([]*cobra.Command, 0, len(cmd.Commands()))
+	for _, c := range cmd.Commands() {
+		if !c.IsAvailableCommand() || c.IsAdditionalHelpTopicCommand() {
+			continue
+		}
+		available = append(available, c)
+	}
+	return available
+}
+
+// GenManTreeSummary reports what GenManTreeIncremental actually did on disk.
+type GenManTreeSummary struct {
+	// Written lists the pages (relative to the target directory) whose
+	// content changed and were therefore promoted to disk.
+	Written []string
+	// Skipped lists the pages whose rendered content was byte-for-byte
+	// identical to what was already on disk, so the file (and its mtime)
+	// was left untouched.
+	Skipped []string
+	// Removed lists pages from a previous run that no longer correspond
+	// to any command in the tree and were deleted.
+	Removed []string
+}
+
+// genManTreeManifest is the name of the bookkeeping file GenManTreeIncremental
+// keeps alongside the generated pages, listing every page name the tree
+// produced on its last run, whether written or skipped. It is how stale pages, for commands removed since then, are
+// found without needing directory listing support from the underlying Fs.
+const genManTreeManifest = ".gen-man-tree.manifest"
+
+// GenManTreeIncremental renders cmd's man page tree the same way GenManTree
+// does, but only touches files in dir whose content actually changed: each
+// page is rendered into an in-memory overlay (afero.NewCopyOnWriteFs) and
+// compared byte-for-byte against the existing file before being promoted to
+// disk. Regenerating a large tree with no real changes therefore performs
+// zero writes and leaves every mtime alone, which is friendlier to
+// downstream caches such as packagers, mandb, or static site generators.
+//
+// Pages left over from commands that no longer exist are removed, tracked
+// using a small manifest file written alongside the pages.
+func GenManTreeIncremental(cmd *cobra.Command, header *GenManHeader, dir string) (*GenManTreeSummary, error) {
+	return GenManTreeIncrementalFromOpts(cmd, GenManTreeOptions{
+		Header:           header,
+		CommandSeparator: "-",
+	}, dir)
+}
+
+// GenManTreeIncrementalFromOpts is GenManTreeIncremental with the full
+// GenManTreeOptions, so incremental regeneration can be combined with
+// Compress/CompressLevel (gzip output) and SectionDirs (manN/ layout) the
+// same way GenManTreeFromOpts supports them. opts.Path is ignored; dir plays
+// that role, matching GenManTreeIncremental.
+func GenManTreeIncrementalFromOpts(cmd *cobra.Command, opts GenManTreeOptions, dir string) (*GenManTreeSummary, error) {
+	header := opts.Header
+	if header == nil {
+		header = &GenManHeader{}
+	}
+	opts.Header = header
+
+	base := docFs
+	if err := base.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	overlay := afero.NewMemMapFs()
+	cowFs := &afero.Afero{Fs: afero.NewCopyOnWriteFs(afero.NewBasePathFs(base.Fs, dir), overlay)}
+	renderOpts := opts
+	renderOpts.Path = "/"
+	if err := genManTreeFromOpts(cmd, renderOpts, cowFs); err != nil {
+		return nil, err
+	}
+
+	overlayFs := &afero.Afero{Fs: overlay}
+	summary := &GenManTreeSummary{}
+	written := map[string]bool{}
+
+	var collect func(c *cobra.Command) error
+	collect = func(c *cobra.Command) error {
+		for _, child := range availableCommands(c) {
+			if err := collect(child); err != nil {
+				return err
+			}
+		}
+		relPath := manPageRelPath(c, header, opts)
+		rendered, err := overlayFs.ReadFile(filepath.Join("/", relPath))
+		if err != nil {
+			return err
+		}
+		written[relPath] = true
+
+		target := filepath.Join(dir, relPath)
+		existing, err := base.ReadFile(target)
+		if err == nil && bytes.Equal(existing, rendered) {
+			summary.Skipped = append(summary.Skipped, relPath)
+			return nil
+		}
+		if err := base.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := base.WriteFile(target, rendered, 0644); err != nil {
+			return err
+		}
+		summary.Written = append(summary.Written, relPath)
+		return nil
+	}
+	if err := collect(cmd); err != nil {
+		return nil, err
+	}
+
+	manifestPath := filepath.Join(dir, genManTreeManifest)
+	previous, prevErr := base.ReadFile(manifestPath)
+	if prevErr == nil {
+		for _, relPath := range strings.Split(string(previous), "\n") {
+			if relPath == "" || written[relPath] {
+				continue
+			}
+			if err := base.Remove(filepath.Join(dir, relPath)); err != nil {
+				return nil, err
+			}
+			summary.Removed = append(summary.Removed, relPath)
+		}
+	}
+
+	names := make([]string, 0, len(written))
+	for name := range written {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	manifest := []byte(strings.Join(names, "\n"))
+	if prevErr != nil || !bytes.Equal(previous, manifest) {
+		if err := base.WriteFile(manifestPath, manifest, 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	return summary, nil
+}
+
+// manPageRelPath returns a command's page path relative to the tree root,
+// matching the layout genManTree itself writes: just the file name, or
+// "manN/<file>" when opts.SectionDirs is set.
+func manPageRelPath(cmd *cobra.Command, header *GenManHeader, opts GenManTreeOptions) string {
+	section := effectiveSection(cmd, header)
+	name := manPageName(cmd, section, opts.CommandSeparator, opts.Compress)
+	if opts.SectionDirs {
+		return filepath.Join("man"+section, name)
+	}
+	return name
+}
+
+// GenMan will generate a man page for the given command and write it to w.
+// The header argument may be nil, however obviously far less information
+// will be included in the man page.
+func GenMan(cmd *cobra.Command, header *GenManHeader, w io.Writer) error {
+	if header == nil {
+		header = &GenManHeader{}
+	}
+	if err := fillHeader(header, cmd.CommandPath(), cmd.DisableAutoGenTag); err != nil {
+		return err
+	}
+
+	b := genMan(cmd, header)
+	_, err := w.Write(md2man.Render(b))
+	return err
+}
+
+// dashEscape joins a command path's space-separated components with a
+// roff-escaped hyphen ("\-" rather than a bare "-"), matching the escaping
+// fillHeader applies to header.Title. A bare "-" inside bold (**...**) text
+// is not reliably escaped by every md2man version, so callers that render
+// command names into man page text must pre-escape it here instead of
+// relying on md2man to do it.
+func dashEscape(name string) string {
+	return strings.Replace(name, " ", "\\-", -1)
+}
+
+func fillHeader(header *GenManHeader, name string, disableAutoGen bool) error {
+	if header.Title == "" {
+		header.Title = strings.ToUpper(dashEscape(name))
+	}
+	if header.Section == "" {
+		header.Section = "1"
+	}
+	if header.Date == nil {
+		now := time.Now()
+		header.Date = &now
+	}
+	header.date = header.Date.Format("Jan 2006")
+	if header.Source == "" && !disableAutoGen {
+		header.Source = "Auto generated by spf13/cobra"
+	}
+	return nil
+}
+
+func manPreamble(buf *bytes.Buffer, header *GenManHeader, cmd *cobra.Command, dashedName string) {
+	description := cmd.Long
+	if description == "" {
+		description = cmd.Short
+	}
+
+	buf.WriteString(fmt.Sprintf(`%% %s(%s)%s
+%% %s
+%% %s
+`, header.Title, header.Section, header.date, header.Source, header.Manual))
+
+	buf.WriteString("# NAME\n")
+	fmt.Fprintf(buf, "%s \\- %s\n\n", dashedName, description)
+
+	buf.WriteString("# SYNOPSIS\n")
+	fmt.Fprintf(buf, "**%s**\n\n", cmd.UseLine())
+
+	if cmd.Long != "" {
+		buf.WriteString("# DESCRIPTION\n")
+		buf.WriteString(cmd.Long + "\n\n")
+	}
+}
+
+func manPrintFlags(buf *bytes.Buffer, flags *pflag.FlagSet) {
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if flag.Hidden || flag.Name == "help" {
+			return
+		}
+		varname := ""
+		if len(flag.NoOptDefVal) == 0 {
+			varname = fmt.Sprintf("=\"%s\"", flag.DefValue)
+		}
+		if flag.Shorthand != "" && flag.ShorthandDeprecated == "" {
+			buf.WriteString(fmt.Sprintf("**-%s**, **--%s**%s\n", flag.Shorthand, flag.Name, varname))
+		} else {
+			buf.WriteString(fmt.Sprintf("**--%s**%s\n", flag.Name, varname))
+		}
+		buf.WriteString("\t" + flag.Usage + "\n\n")
+	})
+}
+
+func manPrintOptions(buf *bytes.Buffer, cmd *cobra.Command) {
+	flags := cmd.NonInheritedFlags()
+	if flags.HasAvailableFlags() {
+		buf.WriteString("# OPTIONS\n")
+		manPrintFlags(buf, flags)
+		buf.WriteString("\n")
+	}
+	flags = cmd.InheritedFlags()
+	if flags.HasAvailableFlags() {
+		buf.WriteString("# OPTIONS INHERITED FROM PARENT COMMANDS\n")
+		manPrintFlags(buf, flags)
+		buf.WriteString("\n")
+	}
+}
+
+func genMan(cmd *cobra.Command, header *GenManHeader) []byte {
+	cmd.InitDefaultHelpFlag()
+	cmd.InitDefaultHelpCmd()
+
+	dashedName := dashEscape(cmd.CommandPath())
+
+	buf := new(bytes.Buffer)
+	manPreamble(buf, header, cmd, dashedName)
+	manPrintOptions(buf, cmd)
+
+	if len(cmd.Example) > 0 {
+		buf.WriteString("# EXAMPLE\n")
+		fmt.Fprintf(buf, "```\n%s\n```\n\n", cmd.Example)
+	}
+
+	available := make([]*cobra.Command, 0)
+	for _, c := range cmd.Commands() {
+		if !c.IsAvailableCommand() || c.IsAdditionalHelpTopicCommand() {
+			continue
+		}
+		available = append(available, c)
+	}
+	sort.Sort(byName(available))
+
+	if len(available) > 0 {
+		buf.WriteString("# COMMANDS\n")
+		for _, c := range available {
+			buf.WriteString(fmt.Sprintf("**%s**\n", c.Name()))
+			buf.WriteString(fmt.Sprintf("\t%s\n", c.Short))
+			buf.WriteString(fmt.Sprintf("\tSee **%s(%s)**.\n\n", dashEscape(c.CommandPath()), header.Section))
+		}
+	}
+
+	if hasSeeAlso(cmd) {
+		buf.WriteString("# SEE ALSO\n")
+		seealsos := make([]string, 0)
+		if cmd.HasParent() {
+			dashParentPath := dashEscape(cmd.Parent().CommandPath())
+			seealsos = append(seealsos, fmt.Sprintf("**%s(%s)**", dashParentPath, header.Section))
+		}
+		for _, c := range available {
+			seealsos = append(seealsos, fmt.Sprintf("**%s(%s)**", dashEscape(c.CommandPath()), header.Section))
+		}
+		buf.WriteString(strings.Join(seealsos, ", ") + "\n")
+	}
+
+	if !cmd.DisableAutoGenTag {
+		buf.WriteString(fmt.Sprintf("# HISTORY\n%s\n", header.date))
+	}
+
+	return buf.Bytes()
+}
+
+type byName []*cobra.Command
+
+func (b byName) Len() int           { return len(b) }
+func (b byName) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byName) Less(i, j int) bool { return b[i].Name() < b[j].Name() }
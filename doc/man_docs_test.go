@@ -3,6 +3,7 @@ package doc
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
 	"fmt"
 	"github.com/spf13/afero"
 	"io/ioutil"
@@ -288,3 +289,283 @@ func BenchmarkGenManToFile(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkGenManToMemMapFs(b *testing.B) {
+	oldDocFs := GetFS()
+	defer SetFS(oldDocFs)
+	SetFS(&afero.Afero{Fs: afero.NewMemMapFs()})
+
+	file, err := docFs.Create("/bench-gen-man")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer file.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := GenMan(rootCmd, nil, file); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestGenManTreeOnMemMapFs(t *testing.T) {
+	oldDocFs := GetFS()
+	defer SetFS(oldDocFs)
+	SetFS(&afero.Afero{Fs: afero.NewMemMapFs()})
+
+	c := &cobra.Command{Use: "do [OPTIONS] arg1 arg2"}
+	sub := &cobra.Command{Use: "sub", Run: emptyRun}
+	c.AddCommand(sub)
+	header := &GenManHeader{Section: "2"}
+
+	if err := GenManTree(c, header, "/manpages"); err != nil {
+		t.Fatalf("GenManTree failed: %s", err.Error())
+	}
+
+	for _, name := range []string{"do.2", "do-sub.2"} {
+		exists, err := docFs.Exists(filepath.Join("/manpages", name))
+		if err != nil {
+			t.Fatalf("Unexpected error checking for %s: %s", name, err)
+		}
+		if !exists {
+			t.Fatalf("Expected file %q to exist on the MemMapFs", name)
+		}
+	}
+}
+
+func TestGenManTreeCompress(t *testing.T) {
+	oldDocFs := GetFS()
+	defer SetFS(oldDocFs)
+	SetFS(&afero.Afero{Fs: afero.NewMemMapFs()})
+
+	header := &GenManHeader{
+		Title:   "Project",
+		Section: "2",
+	}
+
+	if err := GenManTreeFromOpts(echoCmd, GenManTreeOptions{
+		Header:           header,
+		Path:             "/manpages",
+		CommandSeparator: "-",
+		Compress:         true,
+		CompressLevel:    gzip.BestCompression,
+	}); err != nil {
+		t.Fatalf("GenManTreeFromOpts failed: %s", err.Error())
+	}
+
+	gzFile, err := docFs.Open("/manpages/root-echo.2.gz")
+	if err != nil {
+		t.Fatalf("Expected file 'root-echo.2.gz' to exist: %s", err.Error())
+	}
+	defer gzFile.Close()
+
+	gzReader, err := gzip.NewReader(gzFile)
+	if err != nil {
+		t.Fatalf("Expected 'root-echo.2.gz' to be valid gzip: %s", err.Error())
+	}
+	defer gzReader.Close()
+
+	content, err := ioutil.ReadAll(gzReader)
+	if err != nil {
+		t.Fatalf("Failed to decompress 'root-echo.2.gz': %s", err.Error())
+	}
+	output := string(content)
+
+	checkStringContains(t, output, ".SH SEE ALSO")
+	checkStringContains(t, output, ".SH COMMANDS")
+}
+
+func TestGenManTreeCompressDefaultLevel(t *testing.T) {
+	oldDocFs := GetFS()
+	defer SetFS(oldDocFs)
+	SetFS(&afero.Afero{Fs: afero.NewMemMapFs()})
+
+	header := &GenManHeader{
+		Title:   "Project",
+		Section: "2",
+	}
+
+	if err := GenManTreeFromOpts(echoCmd, GenManTreeOptions{
+		Header:           header,
+		Path:             "/manpages",
+		CommandSeparator: "-",
+		Compress:         true,
+	}); err != nil {
+		t.Fatalf("GenManTreeFromOpts failed: %s", err.Error())
+	}
+
+	content, err := docFs.ReadFile("/manpages/root-echo.2.gz")
+	if err != nil {
+		t.Fatalf("Expected file 'root-echo.2.gz' to exist: %s", err.Error())
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("Expected 'root-echo.2.gz' to be valid gzip: %s", err.Error())
+	}
+	reader.Close()
+
+	// CompressLevel left at its zero value must still produce compressed
+	// output, not gzip.NoCompression (which shares the same zero value).
+	uncompressed := new(bytes.Buffer)
+	if err := GenMan(echoCmd, header, uncompressed); err != nil {
+		t.Fatalf("GenMan failed: %s", err.Error())
+	}
+	if len(content) >= uncompressed.Len() {
+		t.Fatalf("Expected default-level gzip output (%d bytes) to be smaller than the uncompressed page (%d bytes)", len(content), uncompressed.Len())
+	}
+}
+
+func TestGenManTreeIncremental(t *testing.T) {
+	oldDocFs := GetFS()
+	defer SetFS(oldDocFs)
+	SetFS(&afero.Afero{Fs: afero.NewMemMapFs()})
+
+	c := &cobra.Command{Use: "do [OPTIONS] arg1 arg2", Run: emptyRun}
+	sub := &cobra.Command{Use: "sub", Run: emptyRun}
+	c.AddCommand(sub)
+	header := &GenManHeader{Section: "2"}
+
+	first, err := GenManTreeIncremental(c, header, "/manpages")
+	if err != nil {
+		t.Fatalf("First GenManTreeIncremental failed: %s", err.Error())
+	}
+	if len(first.Written) != 2 || len(first.Skipped) != 0 {
+		t.Fatalf("Expected first run to write 2 files and skip none, got %+v", first)
+	}
+
+	second, err := GenManTreeIncremental(c, header, "/manpages")
+	if err != nil {
+		t.Fatalf("Second GenManTreeIncremental failed: %s", err.Error())
+	}
+	if len(second.Written) != 0 {
+		t.Fatalf("Expected second run to write zero files, got %+v", second)
+	}
+	if len(second.Skipped) != 2 {
+		t.Fatalf("Expected second run to skip 2 unchanged files, got %+v", second)
+	}
+
+	c.RemoveCommand(sub)
+	third, err := GenManTreeIncremental(c, header, "/manpages")
+	if err != nil {
+		t.Fatalf("Third GenManTreeIncremental failed: %s", err.Error())
+	}
+	if len(third.Removed) != 1 || third.Removed[0] != "do-sub.2" {
+		t.Fatalf("Expected 'do-sub.2' to be reported removed, got %+v", third)
+	}
+	if exists, _ := docFs.Exists(filepath.Join("/manpages", "do-sub.2")); exists {
+		t.Fatalf("Expected 'do-sub.2' to have been deleted")
+	}
+}
+
+func TestGenManTreeSectionDirs(t *testing.T) {
+	oldDocFs := GetFS()
+	defer SetFS(oldDocFs)
+	SetFS(&afero.Afero{Fs: afero.NewMemMapFs()})
+
+	root := &cobra.Command{Use: "do", Run: emptyRun}
+	conf := &cobra.Command{
+		Use:         "conf",
+		Run:         emptyRun,
+		Annotations: map[string]string{ManSectionAnnotation: "5"},
+	}
+	daemon := &cobra.Command{
+		Use:         "daemon",
+		Run:         emptyRun,
+		Annotations: map[string]string{ManSectionAnnotation: "8"},
+	}
+	root.AddCommand(conf, daemon)
+
+	header := &GenManHeader{Section: "1"}
+	if err := GenManTreeFromOpts(root, GenManTreeOptions{
+		Header:           header,
+		Path:             "/share/man",
+		CommandSeparator: "-",
+		SectionDirs:      true,
+	}); err != nil {
+		t.Fatalf("GenManTreeFromOpts failed: %s", err.Error())
+	}
+
+	for dir, name := range map[string]string{
+		"man1": "do.1",
+		"man5": "do-conf.5",
+		"man8": "do-daemon.8",
+	} {
+		exists, err := docFs.Exists(filepath.Join("/share/man", dir, name))
+		if err != nil {
+			t.Fatalf("Unexpected error checking for %s/%s: %s", dir, name, err)
+		}
+		if !exists {
+			t.Fatalf("Expected %s/%s to exist", dir, name)
+		}
+	}
+}
+
+func TestGenManTreeSectionDirsPreventsEscape(t *testing.T) {
+	oldDocFs := GetFS()
+	defer SetFS(oldDocFs)
+	SetFS(&afero.Afero{Fs: afero.NewMemMapFs()})
+
+	evil := &cobra.Command{Use: "../../etc/passwd", Run: emptyRun}
+
+	err := GenManTreeFromOpts(evil, GenManTreeOptions{
+		Header:           &GenManHeader{Section: "1"},
+		Path:             "/share/man",
+		CommandSeparator: "-",
+		SectionDirs:      true,
+	})
+	if err == nil {
+		t.Fatalf("Expected GenManTreeFromOpts to refuse to write outside of Path")
+	}
+}
+
+func TestGenManTreeIncrementalFromOptsComposesCompressAndSectionDirs(t *testing.T) {
+	oldDocFs := GetFS()
+	defer SetFS(oldDocFs)
+	SetFS(&afero.Afero{Fs: afero.NewMemMapFs()})
+
+	root := &cobra.Command{Use: "do", Run: emptyRun}
+	conf := &cobra.Command{
+		Use:         "conf",
+		Run:         emptyRun,
+		Annotations: map[string]string{ManSectionAnnotation: "5"},
+	}
+	root.AddCommand(conf)
+
+	opts := GenManTreeOptions{
+		Header:           &GenManHeader{Section: "1"},
+		CommandSeparator: "-",
+		Compress:         true,
+		SectionDirs:      true,
+	}
+
+	first, err := GenManTreeIncrementalFromOpts(root, opts, "/share/man")
+	if err != nil {
+		t.Fatalf("First GenManTreeIncrementalFromOpts failed: %s", err.Error())
+	}
+	if len(first.Written) != 2 || len(first.Skipped) != 0 {
+		t.Fatalf("Expected first run to write 2 files and skip none, got %+v", first)
+	}
+
+	for dir, name := range map[string]string{
+		"man1": "do.1.gz",
+		"man5": "do-conf.5.gz",
+	} {
+		exists, err := docFs.Exists(filepath.Join("/share/man", dir, name))
+		if err != nil {
+			t.Fatalf("Unexpected error checking for %s/%s: %s", dir, name, err)
+		}
+		if !exists {
+			t.Fatalf("Expected %s/%s to exist", dir, name)
+		}
+	}
+
+	second, err := GenManTreeIncrementalFromOpts(root, opts, "/share/man")
+	if err != nil {
+		t.Fatalf("Second GenManTreeIncrementalFromOpts failed: %s", err.Error())
+	}
+	if len(second.Written) != 0 || len(second.Skipped) != 2 {
+		t.Fatalf("Expected second run to write zero files and skip 2 unchanged, got %+v", second)
+	}
+}
@@ -0,0 +1,40 @@
+package doc
+
+import (
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+)
+
+// docFs is the filesystem used by every function in this package that reads
+// or writes generated documentation. It defaults to the real disk, but can
+// be swapped out (for example with afero.NewMemMapFs()) so generation can be
+// tested, or redirected, without touching the host filesystem.
+var docFs = &afero.Afero{Fs: afero.NewOsFs()}
+
+// SetFS overrides the filesystem used for all documentation generation and
+// allows callers to sandbox, mock, or otherwise wrap the filesystem used by
+// GenManTree, GenManTreeFromOpts and GenManTreeIncremental.
+func SetFS(fs *afero.Afero) {
+	docFs = fs
+}
+
+// GetFS returns the filesystem currently used for documentation generation.
+func GetFS() *afero.Afero {
+	return docFs
+}
+
+// Test to see if we have a reason to print See Also information in docs
+// Basically this is a test for a parent command or a subcommand which is
+// both not deprecated and not the autogenerated help command.
+func hasSeeAlso(cmd *cobra.Command) bool {
+	if cmd.HasParent() {
+		return true
+	}
+	for _, c := range cmd.Commands() {
+		if !c.IsAvailableCommand() || c.IsAdditionalHelpTopicCommand() {
+			continue
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package doc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+var flagb1 bool
+var flags1, flags2 string
+
+var rootCmd = &cobra.Command{
+	Use:   "root",
+	Short: "Root short description",
+	Long:  "Root long description",
+	Run:   emptyRun,
+}
+
+var echoCmd = &cobra.Command{
+	Use:     "echo [string to echo]",
+	Aliases: []string{"say"},
+	Short:   "Echo anything to the screen",
+	Long:    "an utterly useless command for testing",
+	Example: "Just run cobra-test echo",
+	Run:     emptyRun,
+}
+
+var echoSubCmd = &cobra.Command{
+	Use:   "echosub [string to print]",
+	Short: "second sub command for echo",
+	Long:  "an absolutely utterly useless command for testing gendocs!.",
+	Run:   emptyRun,
+}
+
+var timesCmd = &cobra.Command{
+	Use:        "times [# times] [string to echo]",
+	SuggestFor: []string{"counts"},
+	Short:      "Echo anything to the screen more times",
+	Long:       `a slightly useless command for testing.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		for i := 0; i < 2; i++ {
+			fmt.Println("Echo: " + strings.Join(args, " "))
+		}
+	},
+}
+
+var deprecatedCmd = &cobra.Command{
+	Use:        "deprecated [can't do anything here]",
+	Short:      "A command which is deprecated",
+	Long:       `an absolutely utterly useless command for testing deprecation!.`,
+	Deprecated: "Please use echo instead",
+	Run:        emptyRun,
+}
+
+var printCmd = &cobra.Command{
+	Use:    "print [string to print]",
+	Short:  "Print anything to the screen",
+	Long:   `an absolutely utterly useless command for testing.`,
+	Hidden: true,
+	Run:    emptyRun,
+}
+
+func emptyRun(*cobra.Command, []string) {}
+
+func checkStringContains(t *testing.T, found, expected string) {
+	if !strings.Contains(found, expected) {
+		t.Errorf("Expected to contain: \n %v\nGot:\n %v\n", expected, found)
+	}
+}
+
+func checkStringOmits(t *testing.T, found, expected string) {
+	if strings.Contains(found, expected) {
+		t.Errorf("Expected to not contain: \n %v\nGot: %v", expected, found)
+	}
+}
+
+func checkStringMatch(t *testing.T, found, pattern string) {
+	matched, err := regexp.MatchString(pattern, found)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !matched {
+		t.Errorf("Expected to match: \n%v\nGot:\n %v\n", pattern, found)
+	}
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVarP(&flags2, "rootflag", "r", "two", "help message for flag rootflag")
+	rootCmd.PersistentFlags().StringVarP(&flags1, "strtwo", "t", "two", "help message for flag strtwo")
+
+	echoCmd.Flags().BoolVar(&flagb1, "boolone", false, "help message for flag boolone")
+
+	echoCmd.AddCommand(timesCmd, echoSubCmd, deprecatedCmd)
+	rootCmd.AddCommand(echoCmd, printCmd)
+}